@@ -1,31 +1,145 @@
 package ncmdump
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/bogem/id3v2"
 	"github.com/go-flac/flacpicture"
 	"github.com/go-flac/flacvorbis"
 	"github.com/go-flac/go-flac"
+	"golang.org/x/image/webp"
+	"image/png"
+	"io"
+	"net/http"
 	"strings"
+	"time"
 )
 
 const (
 	audioFormatMp3  = "mp3"
 	audioFormatFlac = "flac"
+	audioFormatMp4  = "mp4"
+	audioFormatM4a  = "m4a"
+	audioFormatOgg  = "ogg"
 )
 
 // tag interface for both mp3 and flac
 type Tagger interface {
 	SetCover(buf []byte, mime string) error // set image buffer
+	// SetCoverUrl stores the URL instead of embedding actual art: Mp3Tagger and
+	// FlacTagger write a linked-image reference (MIME "-->" with the URL as
+	// the payload), Mp4Tagger writes the URL as text into the covr atom, and
+	// OggTagger writes it to a COVERART_URL comment. Most players, including
+	// foobar2000, VLC, and iOS Music, don't render linked images, so prefer
+	// SetCoverFromURL; this is kept as a fallback for callers that truly want
+	// the linked-URL behavior.
 	SetCoverUrl(coverUrl string) error
+	SetCoverFromURL(ctx context.Context, url string) error
 	SetTitle(string) error
 	SetAlbum(string) error
 	SetArtist([]string) error
 	SetComment(string) error
+	SetLyrics(lang, text string) error
 	Save() error // must be called
 }
 
+var (
+	pngMagic  = []byte("\x89PNG\r\n\x1a\n")
+	jpegMagic = []byte("\xFF\xD8\xFF")
+	riffMagic = []byte("RIFF")
+	webpMagic = []byte("WEBP")
+)
+
+// sniffCoverMIME inspects buf's magic bytes and returns the real MIME type
+// and file extension for a cover image, since NCM payloads frequently mislabel
+// PNGs as image/jpeg (and vice versa). WebP is reported as image/webp;
+// normalizeCover is what transcodes it to PNG.
+func sniffCoverMIME(buf []byte) (mime, ext string, err error) {
+	switch {
+	case bytes.HasPrefix(buf, pngMagic):
+		return "image/png", "png", nil
+	case bytes.HasPrefix(buf, jpegMagic):
+		return "image/jpeg", "jpg", nil
+	case len(buf) >= 12 && bytes.HasPrefix(buf, riffMagic) && bytes.Equal(buf[8:12], webpMagic):
+		return "image/webp", "webp", nil
+	default:
+		return "", "", errors.New("sniffCoverMIME: unrecognized image format")
+	}
+}
+
+// normalizeCover sniffs buf's real format and, when it disagrees with mime,
+// returns the corrected buffer/MIME pair. WebP is transcoded to PNG so
+// tag consumers that only support the two canonical cover types still see art.
+func normalizeCover(buf []byte, mime string) ([]byte, string, error) {
+	sniffed, _, err := sniffCoverMIME(buf)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if sniffed == "image/webp" {
+		img, err := webp.Decode(bytes.NewReader(buf))
+		if err != nil {
+			return nil, "", fmt.Errorf("normalizeCover: decode webp: %w", err)
+		}
+		var out bytes.Buffer
+		if err := png.Encode(&out, img); err != nil {
+			return nil, "", fmt.Errorf("normalizeCover: encode png: %w", err)
+		}
+		return out.Bytes(), "image/png", nil
+	}
+
+	return buf, sniffed, nil
+}
+
+// defaultCoverHTTPClient is what fetchCover uses when given a nil client.
+// Every SetCoverFromURL passes nil, so covers fetched through a Tagger always
+// use this client; callers cannot set their own timeout, user agent or proxy.
+var defaultCoverHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// maxCoverFetchSize caps how much of a remote cover image SetCoverFromURL
+// will read into memory.
+const maxCoverFetchSize = 8 << 20 // 8 MiB
+
+// fetchCover downloads url with client (falling back to defaultCoverHTTPClient
+// when nil), caps the body at maxCoverFetchSize, and sniffs the real MIME type
+// of what it got back.
+func fetchCover(ctx context.Context, url string, client *http.Client) (buf []byte, mime string, err error) {
+	if client == nil {
+		client = defaultCoverHTTPClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetchCover: unexpected status fetching %s: %s", url, resp.Status)
+	}
+
+	buf, err = io.ReadAll(io.LimitReader(resp.Body, maxCoverFetchSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(buf) > maxCoverFetchSize {
+		return nil, "", fmt.Errorf("fetchCover: cover from %s exceeds %d bytes", url, maxCoverFetchSize)
+	}
+
+	mime, _, err = sniffCoverMIME(buf)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetchCover: %w", err)
+	}
+	return buf, mime, nil
+}
+
 type Mp3Tagger struct {
 	tag *id3v2.Tag
 }
@@ -43,6 +157,10 @@ func NewMp3Tagger(path string) (*Mp3Tagger, error) {
 }
 
 func (m *Mp3Tagger) SetCover(buf []byte, mime string) error {
+	buf, mime, err := normalizeCover(buf, mime)
+	if err != nil {
+		return err
+	}
 
 	m.tag.AddAttachedPicture(id3v2.PictureFrame{
 		Encoding:    id3v2.EncodingISO,
@@ -66,6 +184,14 @@ func (m *Mp3Tagger) SetCoverUrl(coverUrl string) error {
 	return nil
 }
 
+func (m *Mp3Tagger) SetCoverFromURL(ctx context.Context, url string) error {
+	buf, mime, err := fetchCover(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	return m.SetCover(buf, mime)
+}
+
 func (m *Mp3Tagger) SetTitle(title string) error {
 	if name := m.tag.Title(); name == "" {
 		m.tag.SetTitle(title)
@@ -105,6 +231,21 @@ func (m *Mp3Tagger) SetComment(comment string) error {
 	return nil
 }
 
+func (m *Mp3Tagger) SetLyrics(lang, text string) error {
+	if frames := m.tag.GetFrames(m.tag.CommonID("Unsynchronised lyrics/text transcription")); len(frames) == 0 {
+		if lang == "" {
+			lang = "XXX"
+		}
+		m.tag.AddUnsynchronisedLyricsFrame(id3v2.UnsynchronisedLyricsFrame{
+			Encoding:          id3v2.EncodingUTF8,
+			Language:          lang,
+			ContentDescriptor: "",
+			Lyrics:            text,
+		})
+	}
+	return nil
+}
+
 func (m *Mp3Tagger) Save() error {
 	err := m.tag.Save()
 	err = m.tag.Close()
@@ -112,9 +253,10 @@ func (m *Mp3Tagger) Save() error {
 }
 
 type FlacTagger struct {
-	path string
-	file *flac.File
-	cmts *flacvorbis.MetaDataBlockVorbisComment
+	path            string
+	file            *flac.File
+	cmts            *flacvorbis.MetaDataBlockVorbisComment
+	existCommentIdx int
 }
 
 func NewFlacTagger(path string) (*FlacTagger, error) {
@@ -125,9 +267,11 @@ func NewFlacTagger(path string) (*FlacTagger, error) {
 	}
 
 	var cmtmeta *flac.MetaDataBlock
-	for _, m := range f.Meta {
+	existCommentIdx := -1
+	for i, m := range f.Meta {
 		if m.Type == flac.VorbisComment {
 			cmtmeta = m
+			existCommentIdx = i
 			break
 		}
 	}
@@ -145,10 +289,16 @@ func NewFlacTagger(path string) (*FlacTagger, error) {
 	tagger.file = f
 	tagger.cmts = cmts
 	tagger.path = path
+	tagger.existCommentIdx = existCommentIdx
 	return tagger, nil
 }
 
 func (f *FlacTagger) SetCover(buf []byte, mime string) error {
+	buf, mime, err := normalizeCover(buf, mime)
+	if err != nil {
+		return err
+	}
+
 	picture, err := flacpicture.NewFromImageData(flacpicture.PictureTypeFrontCover, "Front cover", buf, mime)
 	if err == nil {
 		picturemeta := picture.Marshal()
@@ -170,6 +320,14 @@ func (f *FlacTagger) SetCoverUrl(coverUrl string) error {
 	return nil
 }
 
+func (f *FlacTagger) SetCoverFromURL(ctx context.Context, url string) error {
+	buf, mime, err := fetchCover(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	return f.SetCover(buf, mime)
+}
+
 func (f *FlacTagger) SetTitle(title string) error {
 	if titles, err := f.cmts.Get(flacvorbis.FIELD_TITLE); err != nil {
 		return err
@@ -204,9 +362,27 @@ func (f *FlacTagger) SetComment(string) error {
 	return nil
 }
 
+func (f *FlacTagger) SetLyrics(lang, text string) error {
+	if lyrics, err := f.cmts.Get("LYRICS"); err != nil {
+		return err
+	} else if len(lyrics) == 0 {
+		return f.cmts.Add("LYRICS", text)
+	}
+	return nil
+}
+
 func (f *FlacTagger) Save() error {
+	// f.cmts is parsed from the pre-existing VorbisComment block in
+	// NewFlacTagger, so it already carries forward every field the Set*
+	// helpers didn't touch (TRACKNUMBER, DATE, GENRE, REPLAYGAIN_*, ...).
+	// Replacing the block in place instead of appending is what avoids the
+	// duplicate-VorbisComment-block bug.
 	res := f.cmts.Marshal()
-	f.file.Meta = append(f.file.Meta, &res)
+	if f.existCommentIdx >= 0 {
+		f.file.Meta[f.existCommentIdx] = &res
+	} else {
+		f.file.Meta = append(f.file.Meta, &res)
+	}
 	return f.file.Save(f.path)
 }
 
@@ -218,6 +394,10 @@ func NewTagger(input, format string) (Tagger, error) {
 		tagger, err = NewMp3Tagger(input)
 	case audioFormatFlac:
 		tagger, err = NewFlacTagger(input)
+	case audioFormatMp4, audioFormatM4a:
+		tagger, err = NewMp4Tagger(input)
+	case audioFormatOgg:
+		tagger, err = NewOggTagger(input)
 	default:
 		err = errors.New(fmt.Sprintf("format: %s is not supportted", format))
 	}
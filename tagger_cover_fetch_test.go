@@ -0,0 +1,51 @@
+package ncmdump
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchCoverReturnsSniffedMIME(t *testing.T) {
+	pngBuf := encodeTestPNG(t, 3, 3)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngBuf)
+	}))
+	defer srv.Close()
+
+	buf, mime, err := fetchCover(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatalf("fetchCover: %v", err)
+	}
+	if mime != "image/png" {
+		t.Errorf("mime = %q, want image/png", mime)
+	}
+	if _, err := png.Decode(bytes.NewReader(buf)); err != nil {
+		t.Errorf("fetched cover does not decode as PNG: %v", err)
+	}
+}
+
+func TestFetchCoverRejectsNonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if _, _, err := fetchCover(context.Background(), srv.URL, nil); err == nil {
+		t.Errorf("fetchCover: expected error for a 404 response, got none")
+	}
+}
+
+func TestFetchCoverRejectsOversizedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, maxCoverFetchSize+1))
+	}))
+	defer srv.Close()
+
+	if _, _, err := fetchCover(context.Background(), srv.URL, nil); err == nil {
+		t.Errorf("fetchCover: expected error for a body over maxCoverFetchSize, got none")
+	}
+}
@@ -0,0 +1,97 @@
+package ncmdump
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-flac/flacvorbis"
+	flac "github.com/go-flac/go-flac"
+)
+
+func newTestFlacFile(t *testing.T, cmts *flacvorbis.MetaDataBlockVorbisComment) string {
+	t.Helper()
+
+	cmtBlock := cmts.Marshal()
+	f := &flac.File{
+		Meta: []*flac.MetaDataBlock{
+			{Type: flac.StreamInfo, Data: make([]byte, 34)},
+			&cmtBlock,
+		},
+		// go-flac's reader indexes into Frames unconditionally, so a fixture
+		// with none panics on the re-parse below instead of exercising Save.
+		Frames: []byte{0xFF, 0xF8, 0x00, 0x00},
+	}
+
+	path := filepath.Join(t.TempDir(), "test.flac")
+	if err := f.Save(path); err != nil {
+		t.Fatalf("save fixture: %v", err)
+	}
+	return path
+}
+
+// TestFlacTaggerSaveReplacesExistingComment guards against the
+// double-VorbisComment-block bug: Save must overwrite the pre-existing
+// block in place rather than appending a second one, and fields the Set*
+// helpers never touched must survive untouched.
+func TestFlacTaggerSaveReplacesExistingComment(t *testing.T) {
+	cmts := flacvorbis.New()
+	cmts.Add("TRACKNUMBER", "7")
+	path := newTestFlacFile(t, cmts)
+
+	tagger, err := NewFlacTagger(path)
+	if err != nil {
+		t.Fatalf("NewFlacTagger: %v", err)
+	}
+	if err := tagger.SetTitle("New Title"); err != nil {
+		t.Fatalf("SetTitle: %v", err)
+	}
+	if err := tagger.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile after save: %v", err)
+	}
+
+	var comments []*flac.MetaDataBlock
+	for _, m := range f.Meta {
+		if m.Type == flac.VorbisComment {
+			comments = append(comments, m)
+		}
+	}
+	if len(comments) != 1 {
+		t.Fatalf("got %d VorbisComment blocks after Save, want exactly 1", len(comments))
+	}
+
+	parsed, err := flacvorbis.ParseFromMetaDataBlock(*comments[0])
+	if err != nil {
+		t.Fatalf("parse saved comment block: %v", err)
+	}
+	if titles, _ := parsed.Get(flacvorbis.FIELD_TITLE); len(titles) != 1 || titles[0] != "New Title" {
+		t.Errorf("TITLE = %v, want [New Title]", titles)
+	}
+	if tracks, _ := parsed.Get("TRACKNUMBER"); len(tracks) != 1 || tracks[0] != "7" {
+		t.Errorf("TRACKNUMBER = %v, want [7] (must survive the replace-in-place Save)", tracks)
+	}
+}
+
+// TestFlacTaggerSetLyricsSkipsIfAlreadySet matches the "skip if already set"
+// idiom FlacTagger's SetTitle, SetAlbum and SetArtist follow.
+func TestFlacTaggerSetLyricsSkipsIfAlreadySet(t *testing.T) {
+	cmts := flacvorbis.New()
+	cmts.Add("LYRICS", "original lyrics")
+	path := newTestFlacFile(t, cmts)
+
+	tagger, err := NewFlacTagger(path)
+	if err != nil {
+		t.Fatalf("NewFlacTagger: %v", err)
+	}
+	if err := tagger.SetLyrics("eng", "new lyrics"); err != nil {
+		t.Fatalf("SetLyrics: %v", err)
+	}
+
+	if lyrics, _ := tagger.cmts.Get("LYRICS"); len(lyrics) != 1 || lyrics[0] != "original lyrics" {
+		t.Errorf("LYRICS = %v, want unchanged [original lyrics]", lyrics)
+	}
+}
@@ -0,0 +1,78 @@
+package ncmdump
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bogem/id3v2"
+)
+
+// newTestMp3File writes a tagless MP3 file; id3v2.Open creates a fresh tag
+// when the file has no existing ID3v2 header, so the body bytes just need
+// to exist, not decode as real audio.
+func newTestMp3File(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, []byte{0xFF, 0xFB, 0x90, 0x00}, 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+func TestMp3TaggerSetLyricsRoundTrip(t *testing.T) {
+	path := newTestMp3File(t)
+
+	tagger, err := NewMp3Tagger(path)
+	if err != nil {
+		t.Fatalf("NewMp3Tagger: %v", err)
+	}
+	if err := tagger.SetLyrics("eng", "hello world"); err != nil {
+		t.Fatalf("SetLyrics: %v", err)
+	}
+	if err := tagger.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	tag, err := id3v2.Open(path, id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatalf("id3v2.Open after Save: %v", err)
+	}
+	defer tag.Close()
+
+	frames := tag.GetFrames(tag.CommonID("Unsynchronised lyrics/text transcription"))
+	if len(frames) != 1 {
+		t.Fatalf("got %d lyrics frames, want 1", len(frames))
+	}
+	lyrics, ok := frames[0].(id3v2.UnsynchronisedLyricsFrame)
+	if !ok {
+		t.Fatalf("frame is %T, want id3v2.UnsynchronisedLyricsFrame", frames[0])
+	}
+	if lyrics.Lyrics != "hello world" || lyrics.Language != "eng" {
+		t.Errorf("lyrics = %+v, want Lyrics=%q Language=%q", lyrics, "hello world", "eng")
+	}
+}
+
+func TestMp3TaggerSetLyricsSkipsIfAlreadySet(t *testing.T) {
+	path := newTestMp3File(t)
+
+	tagger, err := NewMp3Tagger(path)
+	if err != nil {
+		t.Fatalf("NewMp3Tagger: %v", err)
+	}
+	if err := tagger.SetLyrics("eng", "first"); err != nil {
+		t.Fatalf("SetLyrics: %v", err)
+	}
+	if err := tagger.SetLyrics("fra", "second"); err != nil {
+		t.Fatalf("SetLyrics: %v", err)
+	}
+
+	frames := tagger.tag.GetFrames(tagger.tag.CommonID("Unsynchronised lyrics/text transcription"))
+	if len(frames) != 1 {
+		t.Fatalf("got %d lyrics frames, want 1", len(frames))
+	}
+	lyrics := frames[0].(id3v2.UnsynchronisedLyricsFrame)
+	if lyrics.Lyrics != "first" {
+		t.Errorf("Lyrics = %q, want unchanged %q", lyrics.Lyrics, "first")
+	}
+}
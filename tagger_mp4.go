@@ -0,0 +1,241 @@
+package ncmdump
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abema/go-mp4"
+)
+
+// iTunes-style metadata atoms under moov/udta/meta/ilst that we know how to
+// read and write. See https://developer.apple.com/library/archive/documentation/QuickTime/QTFF/Metadata/Metadata.html
+const (
+	ilstTitle  = "\xa9nam" // ©nam; the atom name holds the single byte 0xA9, not UTF-8 "©"
+	ilstAlbum  = "\xa9alb"
+	ilstArtist = "\xa9ART"
+	ilstCmt    = "\xa9cmt"
+	ilstLyrics = "\xa9lyr"
+	ilstCover  = "covr"
+)
+
+// ilstBoxType is "ilst" as a mp4.BoxType; it has no exported
+// mp4.BoxTypeIlst() constant, so it's built once with StrToBoxType.
+var ilstBoxType = mp4.StrToBoxType("ilst")
+
+// iTunes metadata "data" atom well-known known type indicators (DataType in
+// its box). Text atoms (©nam, ©alb, ...) are type 1; covr must carry the
+// actual image type or strict players won't render it.
+const (
+	mp4DataTypeText = 1
+	mp4DataTypeJPEG = 13
+	mp4DataTypePNG  = 14
+)
+
+// ilstValue is one tag atom's data payload plus the DataType it must be
+// marshaled with; text and image atoms use different DataTypes.
+type ilstValue struct {
+	data     []byte
+	dataType uint32
+}
+
+type Mp4Tagger struct {
+	path string
+	ilst map[string]ilstValue
+}
+
+func NewMp4Tagger(path string) (*Mp4Tagger, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ilst := make(map[string]ilstValue)
+	_, err = mp4.ReadBoxStructure(f, func(h *mp4.ReadHandle) (interface{}, error) {
+		switch {
+		case h.BoxInfo.Type == mp4.BoxTypeMoov() || h.BoxInfo.Type == mp4.BoxTypeUdta() ||
+			h.BoxInfo.Type == mp4.BoxTypeMeta() || h.BoxInfo.Type == ilstBoxType:
+			// moov, udta, meta, ilst: keep walking down towards the tag atoms
+			return h.Expand()
+		case len(h.Path) > 1 && h.Path[len(h.Path)-2] == ilstBoxType:
+			// a tag atom such as ©nam or covr (h.Path ends with the box itself,
+			// so its parent is the second-to-last entry): descend into its data child
+			return h.Expand()
+		case h.BoxInfo.Type == mp4.BoxTypeData() && len(h.Path) > 1 && h.Path[len(h.Path)-2] != ilstBoxType:
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			if data, ok := box.(*mp4.Data); ok {
+				ilst[string(h.Path[len(h.Path)-2][:])] = ilstValue{data: data.Data, dataType: data.DataType}
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tagger := new(Mp4Tagger)
+	tagger.path = path
+	tagger.ilst = ilst
+	return tagger, nil
+}
+
+func (m *Mp4Tagger) SetCover(buf []byte, mime string) error {
+	buf, mime, err := normalizeCover(buf, mime)
+	if err != nil {
+		return err
+	}
+
+	var dataType uint32
+	switch mime {
+	case "image/png":
+		dataType = mp4DataTypePNG
+	case "image/jpeg":
+		dataType = mp4DataTypeJPEG
+	default:
+		return fmt.Errorf("Mp4Tagger.SetCover: unsupported cover MIME %q", mime)
+	}
+	m.ilst[ilstCover] = ilstValue{data: buf, dataType: dataType}
+	return nil
+}
+
+func (m *Mp4Tagger) SetCoverUrl(coverUrl string) error {
+	m.ilst[ilstCover] = ilstValue{data: []byte(coverUrl), dataType: mp4DataTypeText}
+	return nil
+}
+
+func (m *Mp4Tagger) SetCoverFromURL(ctx context.Context, url string) error {
+	buf, mime, err := fetchCover(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	return m.SetCover(buf, mime)
+}
+
+func (m *Mp4Tagger) SetTitle(title string) error {
+	if _, ok := m.ilst[ilstTitle]; !ok {
+		m.ilst[ilstTitle] = ilstValue{data: []byte(title), dataType: mp4DataTypeText}
+	}
+	return nil
+}
+
+func (m *Mp4Tagger) SetAlbum(album string) error {
+	if _, ok := m.ilst[ilstAlbum]; !ok {
+		m.ilst[ilstAlbum] = ilstValue{data: []byte(album), dataType: mp4DataTypeText}
+	}
+	return nil
+}
+
+func (m *Mp4Tagger) SetArtist(artists []string) error {
+	if _, ok := m.ilst[ilstArtist]; !ok && len(artists) > 0 {
+		m.ilst[ilstArtist] = ilstValue{data: []byte(strings.Join(artists, "/")), dataType: mp4DataTypeText}
+	}
+	return nil
+}
+
+func (m *Mp4Tagger) SetComment(comment string) error {
+	if _, ok := m.ilst[ilstCmt]; !ok {
+		m.ilst[ilstCmt] = ilstValue{data: []byte(comment), dataType: mp4DataTypeText}
+	}
+	return nil
+}
+
+func (m *Mp4Tagger) SetLyrics(lang, text string) error {
+	if _, ok := m.ilst[ilstLyrics]; !ok {
+		m.ilst[ilstLyrics] = ilstValue{data: []byte(text), dataType: mp4DataTypeText}
+	}
+	return nil
+}
+
+// Save rewrites the file box by box: containers on the path down to ilst are
+// re-emitted with their (possibly changed) children, the ilst box itself is
+// rebuilt from m.ilst, and everything else is copied through untouched. This
+// needs a real io.WriteSeeker, so it writes to a sibling temp file and
+// renames it over the original rather than building the output in memory.
+func (m *Mp4Tagger) Save() error {
+	src, err := os.Open(m.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".ncmdump-mp4-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	w := mp4.NewWriter(tmp)
+	_, err = mp4.ReadBoxStructure(src, func(h *mp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type {
+		case mp4.BoxTypeMoov(), mp4.BoxTypeUdta(), mp4.BoxTypeMeta():
+			if _, err := w.StartBox(&h.BoxInfo); err != nil {
+				return nil, err
+			}
+			// copy the container's own payload (meta's version/flags) before its children
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := mp4.Marshal(w, box, h.BoxInfo.Context); err != nil {
+				return nil, err
+			}
+			children, err := h.Expand()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := w.EndBox(); err != nil {
+				return nil, err
+			}
+			return children, nil
+		case ilstBoxType:
+			return nil, writeIlst(w, &h.BoxInfo, m.ilst)
+		default:
+			return nil, w.CopyBox(src, &h.BoxInfo)
+		}
+	})
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, m.path)
+}
+
+func writeIlst(w *mp4.Writer, bi *mp4.BoxInfo, ilst map[string]ilstValue) error {
+	if _, err := w.StartBox(bi); err != nil {
+		return err
+	}
+	for tag, val := range ilst {
+		var tagType mp4.BoxType
+		copy(tagType[:], tag)
+		entry := mp4.BoxInfo{Type: tagType}
+		if _, err := w.StartBox(&entry); err != nil {
+			return err
+		}
+		dataBox := mp4.BoxInfo{Type: mp4.BoxTypeData()}
+		if _, err := w.StartBox(&dataBox); err != nil {
+			return err
+		}
+		if _, err := mp4.Marshal(w, &mp4.Data{DataType: val.dataType, Data: val.data}, mp4.Context{}); err != nil {
+			return err
+		}
+		if _, err := w.EndBox(); err != nil { // data
+			return err
+		}
+		if _, err := w.EndBox(); err != nil { // tag atom
+			return err
+		}
+	}
+	_, err := w.EndBox() // ilst
+	return err
+}
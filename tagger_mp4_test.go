@@ -0,0 +1,106 @@
+package ncmdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildBox wraps payload in a standard ISO-BMFF box: a 4-byte big-endian size
+// followed by the 4-byte type and the payload itself.
+func buildBox(boxType string, payload []byte) []byte {
+	buf := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(8+len(payload)))
+	copy(buf[4:8], boxType)
+	copy(buf[8:], payload)
+	return buf
+}
+
+// buildIlstTagAtom builds one ilst child atom (e.g. "covr") wrapping a single
+// "data" atom, matching the layout writeIlst/NewMp4Tagger read and write.
+func buildIlstTagAtom(tag string, dataType uint32, data []byte) []byte {
+	dataPayload := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint32(dataPayload[0:4], dataType)
+	// bytes 4:8 are the reserved locale/country indicator, left zeroed
+	copy(dataPayload[8:], data)
+	return buildBox(tag, buildBox("data", dataPayload))
+}
+
+// newTestMp4File builds a minimal ftyp/moov/udta/meta/ilst/mdat fixture with
+// the given ilst tag atoms, just enough for Mp4Tagger to parse and rewrite.
+func newTestMp4File(t *testing.T, tagAtoms [][]byte) string {
+	t.Helper()
+
+	var ilstPayload []byte
+	for _, atom := range tagAtoms {
+		ilstPayload = append(ilstPayload, atom...)
+	}
+	ilst := buildBox("ilst", ilstPayload)
+	meta := buildBox("meta", append(make([]byte, 4), ilst...)) // 4-byte full-box version/flags
+	udta := buildBox("udta", meta)
+	moov := buildBox("moov", udta)
+	ftyp := buildBox("ftyp", []byte("isom\x00\x00\x00\x00isom"))
+	mdat := buildBox("mdat", []byte{0x00, 0x01, 0x02, 0x03})
+
+	var out bytes.Buffer
+	out.Write(ftyp)
+	out.Write(moov)
+	out.Write(mdat)
+
+	path := filepath.Join(t.TempDir(), "test.m4a")
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+// TestMp4TaggerSaveRoundTrip guards against covr being mistagged as text: the
+// rewritten cover atom must keep an image DataType, and an ilst atom this
+// package doesn't special-case (trkn) must survive the rewrite unchanged.
+func TestMp4TaggerSaveRoundTrip(t *testing.T) {
+	oldCover := bytes.Repeat([]byte{0xAA}, 16)
+	trknData := []byte{0x00, 0x01, 0x00, 0x00}
+	path := newTestMp4File(t, [][]byte{
+		buildIlstTagAtom(ilstCover, mp4DataTypeJPEG, oldCover),
+		buildIlstTagAtom("trkn", mp4DataTypeText, trknData),
+	})
+
+	tagger, err := NewMp4Tagger(path)
+	if err != nil {
+		t.Fatalf("NewMp4Tagger: %v", err)
+	}
+
+	newCover := append([]byte{0xFF, 0xD8, 0xFF}, bytes.Repeat([]byte{0x00}, 16)...)
+	if err := tagger.SetCover(newCover, "image/jpeg"); err != nil {
+		t.Fatalf("SetCover: %v", err)
+	}
+	if err := tagger.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	saved, err := NewMp4Tagger(path)
+	if err != nil {
+		t.Fatalf("NewMp4Tagger after Save: %v", err)
+	}
+
+	cover, ok := saved.ilst[ilstCover]
+	if !ok {
+		t.Fatalf("covr atom missing after Save")
+	}
+	if cover.dataType != mp4DataTypeJPEG {
+		t.Errorf("covr DataType = %d, want %d (JPEG)", cover.dataType, mp4DataTypeJPEG)
+	}
+	if !bytes.Equal(cover.data, newCover) {
+		t.Errorf("covr data changed unexpectedly across Save")
+	}
+
+	trkn, ok := saved.ilst["trkn"]
+	if !ok {
+		t.Fatalf("trkn atom missing after Save")
+	}
+	if trkn.dataType != mp4DataTypeText || !bytes.Equal(trkn.data, trknData) {
+		t.Errorf("trkn = %+v, want unchanged DataType %d and data %v", trkn, mp4DataTypeText, trknData)
+	}
+}
@@ -0,0 +1,365 @@
+package ncmdump
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+
+	"github.com/go-flac/flacvorbis"
+	flac "github.com/go-flac/go-flac"
+)
+
+// oggVorbisCommentHeader marks the second packet of a Vorbis stream (the
+// comment header): packet type 3, followed by "vorbis".
+var oggVorbisCommentHeader = append([]byte{0x03}, []byte("vorbis")...)
+
+// oggMaxSegments is the largest a page's segment (lacing) table can be;
+// Ogg pages that hold more than 255*255 bytes of packet data must continue
+// onto a following page.
+const oggMaxSegments = 255
+
+// oggPage is one fully-parsed Ogg page: the fields of its header plus its
+// decoded segment table and packet body.
+type oggPage struct {
+	headerType byte
+	granule    int64
+	serial     uint32
+	sequence   uint32
+	segments   []byte
+	body       []byte
+}
+
+func parseOggPages(data []byte) ([]oggPage, error) {
+	var pages []oggPage
+	pos := 0
+	for pos < len(data) {
+		page, next, err := parseOggPage(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+		pos = next
+	}
+	return pages, nil
+}
+
+func parseOggPage(data []byte, pos int) (oggPage, int, error) {
+	if pos+27 > len(data) || !bytes.Equal(data[pos:pos+4], []byte("OggS")) {
+		return oggPage{}, 0, errors.New("ogg: expected page capture pattern (OggS)")
+	}
+
+	headerType := data[pos+5]
+	granule := int64(binary.LittleEndian.Uint64(data[pos+6 : pos+14]))
+	serial := binary.LittleEndian.Uint32(data[pos+14 : pos+18])
+	sequence := binary.LittleEndian.Uint32(data[pos+18 : pos+22])
+	segCount := int(data[pos+26])
+
+	segStart := pos + 27
+	if segStart+segCount > len(data) {
+		return oggPage{}, 0, errors.New("ogg: truncated segment table")
+	}
+	segments := append([]byte{}, data[segStart:segStart+segCount]...)
+
+	bodyStart := segStart + segCount
+	bodyLen := 0
+	for _, s := range segments {
+		bodyLen += int(s)
+	}
+	if bodyStart+bodyLen > len(data) {
+		return oggPage{}, 0, errors.New("ogg: truncated page body")
+	}
+
+	page := oggPage{
+		headerType: headerType,
+		granule:    granule,
+		serial:     serial,
+		sequence:   sequence,
+		segments:   segments,
+		body:       append([]byte{}, data[bodyStart:bodyStart+bodyLen]...),
+	}
+	return page, bodyStart + bodyLen, nil
+}
+
+// marshalOggPage serializes p, computing and filling in its CRC32.
+func marshalOggPage(p oggPage) []byte {
+	buf := make([]byte, 27+len(p.segments)+len(p.body))
+	copy(buf[0:4], "OggS")
+	buf[4] = 0 // version
+	buf[5] = p.headerType
+	binary.LittleEndian.PutUint64(buf[6:14], uint64(p.granule))
+	binary.LittleEndian.PutUint32(buf[14:18], p.serial)
+	binary.LittleEndian.PutUint32(buf[18:22], p.sequence)
+	// buf[22:26] (checksum) left zeroed until after the CRC is computed
+	buf[26] = byte(len(p.segments))
+	copy(buf[27:], p.segments)
+	copy(buf[27+len(p.segments):], p.body)
+
+	binary.LittleEndian.PutUint32(buf[22:26], oggCRC32(buf))
+	return buf
+}
+
+var oggCRCTable = func() (t [256]uint32) {
+	for i := range t {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = (crc << 1) ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}()
+
+// oggCRC32 computes the checksum Ogg pages use: CRC-32 with polynomial
+// 0x04c11db7, no reflection, zero initial/final XOR.
+func oggCRC32(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = (crc << 8) ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// packetsToPages lays packets (in order, for a single logical stream) out
+// across pages, splitting a packet across a page boundary whenever its
+// segment table would otherwise exceed oggMaxSegments entries.
+func packetsToPages(serial uint32, startSeq uint32, packets [][]byte, bos bool) []oggPage {
+	var pages []oggPage
+	seq := startSeq
+	var segs []byte
+	var body []byte
+	pageContinues := false
+
+	newPage := func() {
+		headerType := byte(0)
+		if bos && len(pages) == 0 {
+			headerType |= 0x02
+		}
+		if pageContinues {
+			headerType |= 0x01
+		}
+		pages = append(pages, oggPage{
+			headerType: headerType,
+			granule:    0,
+			serial:     serial,
+			sequence:   seq,
+			segments:   segs,
+			body:       body,
+		})
+		seq++
+		segs = nil
+		body = nil
+	}
+
+	for _, packet := range packets {
+		off := 0
+		for {
+			n := len(packet) - off
+			lace := n
+			if lace > 255 {
+				lace = 255
+			}
+			segs = append(segs, byte(lace))
+			body = append(body, packet[off:off+lace]...)
+			off += lace
+
+			if len(segs) == oggMaxSegments {
+				unfinished := lace == 255 && off < len(packet)
+				newPage()
+				pageContinues = unfinished
+			}
+			if lace < 255 {
+				pageContinues = false
+				break
+			}
+		}
+	}
+	if len(segs) > 0 {
+		newPage()
+	}
+	return pages
+}
+
+type OggTagger struct {
+	path string
+	data []byte
+
+	pages           []oggPage
+	serial          uint32
+	headerPageCount int // how many of pages[] hold the id/comment/setup packets
+
+	idPacket    []byte
+	setupPacket []byte
+	cmts        *flacvorbis.MetaDataBlockVorbisComment
+}
+
+func NewOggTagger(path string) (*OggTagger, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := parseOggPages(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("ogg: no pages found")
+	}
+
+	serial := pages[0].serial
+	for _, p := range pages {
+		if p.serial != serial {
+			return nil, errors.New("ogg: multiplexed/chained streams are not supported")
+		}
+	}
+
+	var packet []byte
+	var headerPackets [][]byte
+	headerPageCount := 0
+pageLoop:
+	for pageIdx, p := range pages {
+		start := 0
+		for _, segLen := range p.segments {
+			packet = append(packet, p.body[start:start+int(segLen)]...)
+			start += int(segLen)
+			if segLen < 255 {
+				headerPackets = append(headerPackets, packet)
+				packet = nil
+				if len(headerPackets) == 3 {
+					if start != len(p.body) {
+						return nil, errors.New("ogg: vorbis setup header packet does not end at a page boundary, re-muxing not supported")
+					}
+					headerPageCount = pageIdx + 1
+					break pageLoop
+				}
+			}
+		}
+	}
+	if len(headerPackets) < 3 {
+		return nil, errors.New("ogg: could not find the vorbis identification/comment/setup header packets")
+	}
+	if !bytes.HasPrefix(headerPackets[1], oggVorbisCommentHeader) {
+		return nil, errors.New("ogg: second packet is not a vorbis comment header")
+	}
+
+	cmts, err := flacvorbis.ParseFromMetaDataBlock(flac.MetaDataBlock{
+		Type: flac.VorbisComment,
+		Data: headerPackets[1][len(oggVorbisCommentHeader):],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tagger := new(OggTagger)
+	tagger.path = path
+	tagger.data = data
+	tagger.pages = pages
+	tagger.serial = serial
+	tagger.headerPageCount = headerPageCount
+	tagger.idPacket = headerPackets[0]
+	tagger.setupPacket = headerPackets[2]
+	tagger.cmts = cmts
+	return tagger, nil
+}
+
+func (o *OggTagger) SetCover(buf []byte, mime string) error {
+	// Vorbis embeds cover art as a base64 METADATA_BLOCK_PICTURE comment;
+	// OggTagger does not write one, so SetCover (and SetCoverFromURL, which
+	// calls it) returns an error rather than silently dropping the picture.
+	return errors.New("ogg: SetCover is not supported, use SetCoverUrl")
+}
+
+func (o *OggTagger) SetCoverUrl(coverUrl string) error {
+	if urls, err := o.cmts.Get("COVERART_URL"); err != nil {
+		return err
+	} else if len(urls) == 0 {
+		return o.cmts.Add("COVERART_URL", coverUrl)
+	}
+	return nil
+}
+
+func (o *OggTagger) SetCoverFromURL(ctx context.Context, url string) error {
+	buf, mime, err := fetchCover(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	return o.SetCover(buf, mime)
+}
+
+func (o *OggTagger) SetTitle(title string) error {
+	if titles, err := o.cmts.Get(flacvorbis.FIELD_TITLE); err != nil {
+		return err
+	} else if len(titles) == 0 {
+		return o.cmts.Add(flacvorbis.FIELD_TITLE, title)
+	}
+	return nil
+}
+
+func (o *OggTagger) SetAlbum(album string) error {
+	if albums, err := o.cmts.Get(flacvorbis.FIELD_ALBUM); err != nil {
+		return err
+	} else if len(albums) == 0 {
+		return o.cmts.Add(flacvorbis.FIELD_ALBUM, album)
+	}
+	return nil
+}
+
+func (o *OggTagger) SetArtist(artists []string) error {
+	if theArtists, err := o.cmts.Get(flacvorbis.FIELD_ARTIST); err != nil {
+		return err
+	} else if len(theArtists) == 0 {
+		for _, artist := range artists {
+			o.cmts.Add(flacvorbis.FIELD_ARTIST, artist)
+		}
+	}
+	return nil
+}
+
+func (o *OggTagger) SetComment(comment string) error {
+	if comments, err := o.cmts.Get("COMMENT"); err != nil {
+		return err
+	} else if len(comments) == 0 {
+		return o.cmts.Add("COMMENT", comment)
+	}
+	return nil
+}
+
+func (o *OggTagger) SetLyrics(lang, text string) error {
+	if lyrics, err := o.cmts.Get("LYRICS"); err != nil {
+		return err
+	} else if len(lyrics) == 0 {
+		return o.cmts.Add("LYRICS", text)
+	}
+	return nil
+}
+
+// Save rebuilds the id/comment/setup header pages from scratch (the comment
+// packet almost always changes size) and renumbers every later page of the
+// stream to keep Ogg's monotonically increasing page sequence intact; their
+// segment tables and body bytes are otherwise untouched.
+func (o *OggTagger) Save() error {
+	commentPacket := append(append([]byte{}, oggVorbisCommentHeader...), o.cmts.Marshal().Data...)
+
+	headerPages := packetsToPages(o.serial, o.pages[0].sequence,
+		[][]byte{o.idPacket, commentPacket, o.setupPacket}, true)
+
+	var out bytes.Buffer
+	for _, p := range headerPages {
+		out.Write(marshalOggPage(p))
+	}
+
+	seq := o.pages[0].sequence + uint32(len(headerPages))
+	for _, p := range o.pages[o.headerPageCount:] {
+		p.sequence = seq
+		seq++
+		out.Write(marshalOggPage(p))
+	}
+
+	return os.WriteFile(o.path, out.Bytes(), 0644)
+}
@@ -0,0 +1,151 @@
+package ncmdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-flac/flacvorbis"
+	flac "github.com/go-flac/go-flac"
+)
+
+const testOggSerial = 0x1234
+
+// buildTestOggPacket builds a fake, minimally-valid-looking Vorbis
+// identification or setup header packet; our reader/writer never interpret
+// the payload of either, only their position in the packet sequence and
+// their length.
+func buildTestOggPacket(packetType byte, payload []byte) []byte {
+	return append(append([]byte{packetType}, []byte("vorbis")...), payload...)
+}
+
+func newTestOggFile(t *testing.T, cmts *flacvorbis.MetaDataBlockVorbisComment, audio []byte) string {
+	t.Helper()
+
+	idPacket := buildTestOggPacket(0x01, make([]byte, 23)) // version+channels+rate+bitrates+blocksizes+framing
+	commentPacket := append(append([]byte{}, oggVorbisCommentHeader...), cmts.Marshal().Data...)
+	setupPacket := buildTestOggPacket(0x05, bytes.Repeat([]byte{0xAB}, 40))
+
+	pages := packetsToPages(testOggSerial, 0, [][]byte{idPacket, commentPacket, setupPacket}, true)
+	audioPages := packetsToPages(testOggSerial, uint32(len(pages)), [][]byte{audio}, false)
+	audioPages[len(audioPages)-1].headerType |= 0x04 // EOS on the last page
+	pages = append(pages, audioPages...)
+
+	var out bytes.Buffer
+	for _, p := range pages {
+		out.Write(marshalOggPage(p))
+	}
+
+	path := filepath.Join(t.TempDir(), "test.ogg")
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+// checkOggChecksums re-walks every page in data and verifies its stored CRC32
+// matches the page bytes; parseOggPages itself doesn't check this, so a
+// splice that corrupts framing without updating the CRC would otherwise pass
+// silently.
+func checkOggChecksums(t *testing.T, data []byte) {
+	t.Helper()
+	pos := 0
+	for i := 0; pos < len(data); i++ {
+		_, next, err := parseOggPage(data, pos)
+		if err != nil {
+			t.Fatalf("page %d: %v", i, err)
+		}
+		want := binary.LittleEndian.Uint32(data[pos+22 : pos+26])
+		raw := append([]byte{}, data[pos:next]...)
+		binary.LittleEndian.PutUint32(raw[22:26], 0)
+		if got := oggCRC32(raw); got != want {
+			t.Errorf("page %d: stored checksum %#x, computed %#x", i, want, got)
+		}
+		pos = next
+	}
+}
+
+// TestOggTaggerSaveRoundTrip guards against a naive fixed-offset byte
+// splice of the comment packet: after Save, every page must still parse with a
+// correct CRC and a segment table that matches its body length, and page
+// sequence numbers must keep increasing across the header/audio boundary.
+func TestOggTaggerSaveRoundTrip(t *testing.T) {
+	cmts := flacvorbis.New()
+	cmts.Add("ALBUM", "Old Album")
+	audio := bytes.Repeat([]byte{0x42}, 600) // bigger than one segment, smaller than one page
+
+	path := newTestOggFile(t, cmts, audio)
+
+	tagger, err := NewOggTagger(path)
+	if err != nil {
+		t.Fatalf("NewOggTagger: %v", err)
+	}
+	// SetTitle grows the comment packet well past its original size, which is
+	// exactly the case a fixed-offset splice would corrupt.
+	if err := tagger.SetTitle("A Considerably Longer Title Than The Fixture Had Room For"); err != nil {
+		t.Fatalf("SetTitle: %v", err)
+	}
+	if err := tagger.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read saved file: %v", err)
+	}
+	checkOggChecksums(t, saved)
+
+	pages, err := parseOggPages(saved)
+	if err != nil {
+		t.Fatalf("parseOggPages after Save: %v", err)
+	}
+	for i := 1; i < len(pages); i++ {
+		if pages[i-1].sequence >= pages[i].sequence {
+			t.Errorf("page %d sequence %d does not increase from page %d sequence %d",
+				i, pages[i].sequence, i-1, pages[i-1].sequence)
+		}
+	}
+
+	lastPage := pages[len(pages)-1]
+	if lastPage.headerType&0x04 == 0 {
+		t.Errorf("expected the trailing audio page to keep its end-of-stream flag")
+	}
+	if !bytes.Equal(lastPage.body, audio) {
+		t.Errorf("trailing audio page body changed across Save; got %d bytes, want %d", len(lastPage.body), len(audio))
+	}
+
+	var packet []byte
+	var packets [][]byte
+	for _, p := range pages {
+		start := 0
+		for _, segLen := range p.segments {
+			packet = append(packet, p.body[start:start+int(segLen)]...)
+			start += int(segLen)
+			if segLen < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+		if len(packets) >= 3 {
+			break
+		}
+	}
+	if len(packets) < 2 || !bytes.HasPrefix(packets[1], oggVorbisCommentHeader) {
+		t.Fatalf("could not recover the rewritten comment packet")
+	}
+	newCmts, err := flacvorbis.ParseFromMetaDataBlock(flac.MetaDataBlock{
+		Type: flac.VorbisComment,
+		Data: packets[1][len(oggVorbisCommentHeader):],
+	})
+	if err != nil {
+		t.Fatalf("parse rewritten comment packet: %v", err)
+	}
+	if titles, _ := newCmts.Get(flacvorbis.FIELD_TITLE); len(titles) != 1 || titles[0] != "A Considerably Longer Title Than The Fixture Had Room For" {
+		t.Errorf("TITLE = %v, want the new title", titles)
+	}
+	if albums, _ := newCmts.Get("ALBUM"); len(albums) != 1 || albums[0] != "Old Album" {
+		t.Errorf("ALBUM = %v, want [Old Album] to survive untouched", albums)
+	}
+}
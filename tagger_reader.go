@@ -0,0 +1,208 @@
+package ncmdump
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/bogem/id3v2"
+	"github.com/go-flac/flacvorbis"
+	flac "github.com/go-flac/go-flac"
+)
+
+// TagReader mirrors Tagger's fields for read-only access, so a second source
+// of metadata (e.g. ffprobe) can be consulted to fill in gaps left by an NCM
+// payload's own, sometimes-incomplete, JSON.
+type TagReader interface {
+	Title() string
+	Album() string
+	Artists() []string
+	Year() string
+	HasCover() bool
+}
+
+// MergeTags applies fields from src onto dst, but only for fields src
+// actually has a value for. It relies on Tagger's Set* methods already
+// skipping writes when the field is set, the same "skip if already set"
+// pattern SetTitle/SetAlbum use, so dst's existing values always win.
+func MergeTags(dst Tagger, src TagReader) error {
+	if title := src.Title(); title != "" {
+		if err := dst.SetTitle(title); err != nil {
+			return err
+		}
+	}
+	if album := src.Album(); album != "" {
+		if err := dst.SetAlbum(album); err != nil {
+			return err
+		}
+	}
+	if artists := src.Artists(); len(artists) > 0 {
+		if err := dst.SetArtist(artists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Mp3TagReader struct {
+	tag *id3v2.Tag
+}
+
+func NewMp3TagReader(path string) (*Mp3TagReader, error) {
+	tag, err := id3v2.Open(path, id3v2.Options{Parse: true})
+	if err != nil {
+		return nil, err
+	}
+	reader := new(Mp3TagReader)
+	reader.tag = tag
+	return reader, nil
+}
+
+func (r *Mp3TagReader) Title() string { return r.tag.Title() }
+func (r *Mp3TagReader) Album() string { return r.tag.Album() }
+
+func (r *Mp3TagReader) Artists() []string {
+	var artists []string
+	for _, f := range r.tag.GetFrames(r.tag.CommonID("Artist")) {
+		if text, ok := f.(id3v2.TextFrame); ok && text.Text != "" {
+			artists = append(artists, text.Text)
+		}
+	}
+	return artists
+}
+
+func (r *Mp3TagReader) Year() string { return r.tag.Year() }
+
+func (r *Mp3TagReader) HasCover() bool {
+	return len(r.tag.GetFrames(r.tag.CommonID("Attached picture"))) > 0
+}
+
+type FlacTagReader struct {
+	cmts     *flacvorbis.MetaDataBlockVorbisComment
+	hasCover bool
+}
+
+func NewFlacTagReader(path string) (*FlacTagReader, error) {
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := new(FlacTagReader)
+	reader.cmts = flacvorbis.New()
+	for _, m := range f.Meta {
+		switch m.Type {
+		case flac.VorbisComment:
+			cmts, err := flacvorbis.ParseFromMetaDataBlock(*m)
+			if err != nil {
+				return nil, err
+			}
+			reader.cmts = cmts
+		case flac.Picture:
+			reader.hasCover = true
+		}
+	}
+	return reader, nil
+}
+
+func flacComment(cmts *flacvorbis.MetaDataBlockVorbisComment, field string) string {
+	values, err := cmts.Get(field)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (r *FlacTagReader) Title() string { return flacComment(r.cmts, flacvorbis.FIELD_TITLE) }
+func (r *FlacTagReader) Album() string { return flacComment(r.cmts, flacvorbis.FIELD_ALBUM) }
+
+func (r *FlacTagReader) Artists() []string {
+	artists, err := r.cmts.Get(flacvorbis.FIELD_ARTIST)
+	if err != nil {
+		return nil
+	}
+	return artists
+}
+
+func (r *FlacTagReader) Year() string   { return flacComment(r.cmts, "DATE") }
+func (r *FlacTagReader) HasCover() bool { return r.hasCover }
+
+// FFProbeTagReader shells out to ffprobe to read container-level metadata,
+// useful as a fallback source of truth when the NCM payload's own JSON is
+// incomplete or wrong.
+type FFProbeTagReader struct {
+	tags     map[string]string
+	hasCover bool
+}
+
+type ffprobeOutput struct {
+	Format struct {
+		Tags map[string]string `json:"tags"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType   string `json:"codec_type"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+}
+
+func NewFFProbeTagReader(ctx context.Context, path string) (*FFProbeTagReader, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "quiet",
+		"-show_format",
+		"-show_streams",
+		"-print_format", "json",
+		path,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseFFProbeOutput(out)
+}
+
+// parseFFProbeOutput turns ffprobe's JSON report into a FFProbeTagReader;
+// split out from NewFFProbeTagReader so the parsing logic can be tested
+// without actually shelling out to ffprobe.
+func parseFFProbeOutput(out []byte) (*FFProbeTagReader, error) {
+	var probe ffprobeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(probe.Format.Tags))
+	for k, v := range probe.Format.Tags {
+		tags[strings.ToLower(k)] = v
+	}
+
+	reader := new(FFProbeTagReader)
+	reader.tags = tags
+	for _, s := range probe.Streams {
+		if s.Disposition.AttachedPic == 1 {
+			reader.hasCover = true
+		}
+	}
+	return reader, nil
+}
+
+func (r *FFProbeTagReader) Title() string { return r.tags["title"] }
+func (r *FFProbeTagReader) Album() string { return r.tags["album"] }
+
+func (r *FFProbeTagReader) Artists() []string {
+	artist, ok := r.tags["artist"]
+	if !ok || artist == "" {
+		return nil
+	}
+	return strings.Split(artist, "/")
+}
+
+func (r *FFProbeTagReader) Year() string {
+	if year, ok := r.tags["date"]; ok {
+		return year
+	}
+	return r.tags["year"]
+}
+
+func (r *FFProbeTagReader) HasCover() bool { return r.hasCover }
@@ -0,0 +1,87 @@
+package ncmdump
+
+import (
+	"testing"
+
+	"github.com/go-flac/flacvorbis"
+)
+
+// fakeTagReader is a minimal TagReader stub for exercising MergeTags without
+// needing a real media fixture.
+type fakeTagReader struct {
+	title   string
+	album   string
+	artists []string
+}
+
+func (f *fakeTagReader) Title() string     { return f.title }
+func (f *fakeTagReader) Album() string     { return f.album }
+func (f *fakeTagReader) Artists() []string { return f.artists }
+func (f *fakeTagReader) Year() string      { return "" }
+func (f *fakeTagReader) HasCover() bool    { return false }
+
+func TestMergeTagsFillsOnlyMissingFields(t *testing.T) {
+	cmts := flacvorbisCommentsWithTitle(t, "Existing Title")
+	tagger := &FlacTagger{cmts: cmts, existCommentIdx: -1}
+
+	src := &fakeTagReader{title: "Fallback Title", album: "Fallback Album", artists: []string{"A", "B"}}
+	if err := MergeTags(tagger, src); err != nil {
+		t.Fatalf("MergeTags: %v", err)
+	}
+
+	if titles, _ := tagger.cmts.Get(flacvorbis.FIELD_TITLE); len(titles) != 1 || titles[0] != "Existing Title" {
+		t.Errorf("TITLE = %v, want unchanged [Existing Title]", titles)
+	}
+	if albums, _ := tagger.cmts.Get(flacvorbis.FIELD_ALBUM); len(albums) != 1 || albums[0] != "Fallback Album" {
+		t.Errorf("ALBUM = %v, want [Fallback Album] filled in from src", albums)
+	}
+	if artists, _ := tagger.cmts.Get(flacvorbis.FIELD_ARTIST); len(artists) != 2 {
+		t.Errorf("ARTIST = %v, want [A B] filled in from src", artists)
+	}
+}
+
+func TestMergeTagsSkipsEmptyFields(t *testing.T) {
+	cmts := flacvorbisCommentsWithTitle(t, "Existing Title")
+	tagger := &FlacTagger{cmts: cmts, existCommentIdx: -1}
+
+	if err := MergeTags(tagger, &fakeTagReader{}); err != nil {
+		t.Fatalf("MergeTags: %v", err)
+	}
+
+	if albums, _ := tagger.cmts.Get(flacvorbis.FIELD_ALBUM); len(albums) != 0 {
+		t.Errorf("ALBUM = %v, want untouched (src had none)", albums)
+	}
+}
+
+func TestParseFFProbeOutput(t *testing.T) {
+	out := []byte(`{
+		"format": {"tags": {"Title": "A Song", "ARTIST": "A/B"}},
+		"streams": [
+			{"codec_type": "audio", "disposition": {"attached_pic": 0}},
+			{"codec_type": "video", "disposition": {"attached_pic": 1}}
+		]
+	}`)
+
+	reader, err := parseFFProbeOutput(out)
+	if err != nil {
+		t.Fatalf("parseFFProbeOutput: %v", err)
+	}
+	if reader.Title() != "A Song" {
+		t.Errorf("Title() = %q, want %q", reader.Title(), "A Song")
+	}
+	if artists := reader.Artists(); len(artists) != 2 || artists[0] != "A" || artists[1] != "B" {
+		t.Errorf("Artists() = %v, want [A B]", artists)
+	}
+	if !reader.HasCover() {
+		t.Errorf("HasCover() = false, want true (attached_pic stream present)")
+	}
+}
+
+func flacvorbisCommentsWithTitle(t *testing.T, title string) *flacvorbis.MetaDataBlockVorbisComment {
+	t.Helper()
+	cmts := flacvorbis.New()
+	if err := cmts.Add(flacvorbis.FIELD_TITLE, title); err != nil {
+		t.Fatalf("seed TITLE: %v", err)
+	}
+	return cmts
+}
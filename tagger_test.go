@@ -0,0 +1,78 @@
+package ncmdump
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestSniffCoverMIME(t *testing.T) {
+	pngBuf := encodeTestPNG(t, 2, 2)
+	// A minimal RIFF/WEBP header; sniffCoverMIME only inspects the magic
+	// bytes, so the VP8/VP8L payload itself doesn't need to be valid.
+	webpBuf := append([]byte("RIFF\x00\x00\x00\x00WEBP"), 0xAB, 0xCD)
+
+	cases := []struct {
+		name    string
+		buf     []byte
+		mime    string
+		ext     string
+		wantErr bool
+	}{
+		{"png", pngBuf, "image/png", "png", false},
+		{"jpeg", append(append([]byte{}, jpegMagic...), 0x00, 0x01, 0x02), "image/jpeg", "jpg", false},
+		{"webp", webpBuf, "image/webp", "webp", false},
+		{"unrecognized", []byte("not an image"), "", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mime, ext, err := sniffCoverMIME(c.buf)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("sniffCoverMIME(%s): expected error, got none", c.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sniffCoverMIME(%s): unexpected error: %v", c.name, err)
+			}
+			if mime != c.mime || ext != c.ext {
+				t.Errorf("sniffCoverMIME(%s) = (%q, %q), want (%q, %q)", c.name, mime, ext, c.mime, c.ext)
+			}
+		})
+	}
+}
+
+func TestNormalizeCoverCorrectsMislabeledMIME(t *testing.T) {
+	pngBuf := encodeTestPNG(t, 1, 1)
+
+	// NCM payloads frequently mislabel PNGs as image/jpeg; normalizeCover
+	// should trust the magic bytes over the caller-supplied mime.
+	buf, mime, err := normalizeCover(pngBuf, "image/jpeg")
+	if err != nil {
+		t.Fatalf("normalizeCover: %v", err)
+	}
+	if mime != "image/png" {
+		t.Errorf("mime = %q, want image/png", mime)
+	}
+	if !bytes.Equal(buf, pngBuf) {
+		t.Errorf("normalizeCover changed a non-webp buffer's bytes")
+	}
+}
+
+func TestNormalizeCoverRejectsUnrecognizedFormat(t *testing.T) {
+	if _, _, err := normalizeCover([]byte("garbage"), "image/png"); err == nil {
+		t.Errorf("normalizeCover: expected error for unrecognized image data, got none")
+	}
+}
+
+func encodeTestPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("encode test png: %v", err)
+	}
+	return buf.Bytes()
+}